@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// cmafRenditionTTL bounds how long a manifestID's rendition set is kept
+// after its last fragment. There's no stream-teardown hook on the
+// Orchestrator interface to unregister it explicitly when a stream ends,
+// so entries are swept on a timer instead, the same way uploadCache's
+// partials are.
+const cmafRenditionTTL = 10 * time.Minute
+
+// cmafRenditions tracks, per manifestID, which rendition names have had at
+// least one CMAF fragment saved. ServeDASHManifest reads from it instead of
+// depending on an orchestrator-wide method that doesn't exist on the
+// Orchestrator interface, since the set of live renditions for a stream is
+// exactly what saveCMAFFragments already knows as it saves each fragment.
+var cmafRenditions = struct {
+	mu          sync.Mutex
+	byID        map[core.ManifestID][]string
+	lastTouched map[core.ManifestID]time.Time
+}{
+	byID:        map[core.ManifestID][]string{},
+	lastTouched: map[core.ManifestID]time.Time{},
+}
+
+func init() {
+	go sweepCMAFRenditions()
+}
+
+func sweepCMAFRenditions() {
+	for range time.Tick(cmafRenditionTTL / 2) {
+		cmafRenditions.mu.Lock()
+		now := time.Now()
+		for manifestID, touched := range cmafRenditions.lastTouched {
+			if now.Sub(touched) > cmafRenditionTTL {
+				delete(cmafRenditions.byID, manifestID)
+				delete(cmafRenditions.lastTouched, manifestID)
+			}
+		}
+		cmafRenditions.mu.Unlock()
+	}
+}
+
+func registerCMAFRendition(manifestID core.ManifestID, name string) {
+	cmafRenditions.mu.Lock()
+	defer cmafRenditions.mu.Unlock()
+
+	cmafRenditions.lastTouched[manifestID] = time.Now()
+	for _, existing := range cmafRenditions.byID[manifestID] {
+		if existing == name {
+			return
+		}
+	}
+	cmafRenditions.byID[manifestID] = append(cmafRenditions.byID[manifestID], name)
+}
+
+func cmafRenditionsFor(manifestID core.ManifestID) []string {
+	cmafRenditions.mu.Lock()
+	defer cmafRenditions.mu.Unlock()
+	return append([]string(nil), cmafRenditions.byID[manifestID]...)
+}
+
+// renditionName returns the name of the profile that produced res.Data[i],
+// falling back to a positional name if md carries no profile for that
+// index. TranscodeSeg always returns one Data entry per md.Profiles entry
+// in order, so this is the name callers should key per-rendition OS
+// objects on rather than the bare loop index.
+func renditionName(md *core.SegTranscodingMetadata, i int) string {
+	if i < len(md.Profiles) && md.Profiles[i].Name != "" {
+		return md.Profiles[i].Name
+	}
+	return fmt.Sprintf("rendition-%d", i)
+}
+
+// saveCMAFFragments saves the renditions in a DASH-ingest TranscodeResult
+// as distinct init/media OS objects (rather than one flat MPEG-TS object
+// per rendition), so a /manifest MPD can reference them by $Number$.
+func saveCMAFFragments(res *core.TranscodeResult, md *core.SegTranscodingMetadata) *net.TranscodeResult_Data {
+	segments := []*net.TranscodedSegmentData{}
+	for i, d := range res.Data {
+		name := renditionName(md, i)
+		objName := cmafSegmentName(name, md.Container, md.Seq)
+		uri, err := res.OS.SaveData(objName, d)
+		if err != nil {
+			glog.Error("Error saving CMAF fragment: ", err)
+			continue
+		}
+		registerCMAFRendition(md.ManifestID, name)
+		segments = append(segments, &net.TranscodedSegmentData{Url: uri})
+	}
+	return &net.TranscodeResult_Data{
+		Data: &net.TranscodeData{
+			Segments: segments,
+			Sig:      res.Sig,
+		},
+	}
+}
+
+// ServeDASHManifest emits a minimal DASH MPD referencing the orchestrator's
+// OS-stored CMAF fragments via SegmentTemplate, so dash.js/Shaka can play
+// the stream directly without an HLS-to-DASH transmux hop.
+func ServeDASHManifest() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		manifestID := r.URL.Query().Get("manifestid")
+		if manifestID == "" {
+			http.Error(w, "missing manifestid", http.StatusBadRequest)
+			return
+		}
+
+		renditions := cmafRenditionsFor(core.ManifestID(manifestID))
+		if len(renditions) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/dash+xml")
+		fmt.Fprint(w, dashMPD(renditions))
+	})
+}
+
+// dashMPD renders a SegmentTemplate-based MPD for the given renditions.
+func dashMPD(renditionNames []string) string {
+	mpd := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S">
+  <Period id="0">
+`
+	for _, name := range renditionNames {
+		mpd += fmt.Sprintf(`    <AdaptationSet segmentAlignment="true" mimeType="video/mp4">
+      <SegmentTemplate media="%s/$Number$.m4s" initialization="%s/init.mp4" startNumber="1" timescale="1000"/>
+      <Representation id="%s"/>
+    </AdaptationSet>
+`, name, name, name)
+	}
+	mpd += "  </Period>\n</MPD>\n"
+	return mpd
+}
+
+// RegisterSegmentRoutes wires ServeSegment and ServeDASHManifest onto mux,
+// the same way the rest of the orchestrator's HTTP endpoints are
+// registered.
+func RegisterSegmentRoutes(mux *http.ServeMux, orch Orchestrator) {
+	mux.Handle("/segment", ServeSegment(orch))
+	mux.Handle("/manifest", ServeDASHManifest())
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeDASHManifest_MissingManifestID(t *testing.T) {
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/manifest", nil)
+
+	ServeDASHManifest().ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestServeDASHManifest_UnknownManifestID(t *testing.T) {
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/manifest?manifestid=nonexistent", nil)
+
+	ServeDASHManifest().ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestServeDASHManifest_ListsRegisteredRenditions(t *testing.T) {
+	manifestID := core.RandomManifestID()
+	registerCMAFRendition(manifestID, "rendition-0")
+	registerCMAFRendition(manifestID, "rendition-1")
+	// Registering the same rendition twice should not duplicate it in the MPD.
+	registerCMAFRendition(manifestID, "rendition-0")
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/manifest?manifestid="+string(manifestID), nil)
+
+	ServeDASHManifest().ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+
+	mpd := string(body)
+	assert.Equal(t, 1, strings.Count(mpd, `Representation id="rendition-0"`))
+	assert.Equal(t, 1, strings.Count(mpd, `Representation id="rendition-1"`))
+}
+
+func TestRegisterSegmentRoutes(t *testing.T) {
+	orch := &mockOrchestrator{}
+	mux := http.NewServeMux()
+	RegisterSegmentRoutes(mux, orch)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/manifest?manifestid=missing", nil)
+	mux.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -0,0 +1,64 @@
+// Package orchtest provides black-box conformance-testing helpers for any
+// orchestrator implementing ServeSegment's HTTP contract. It intentionally
+// only depends on the exported net protobuf types, not on server package
+// internals, so it can be vendored by downstream projects that only talk
+// to an orchestrator over HTTP.
+//
+// Pairing a directive-carrying manifestID (see the testtools fault
+// injection build tag in package server) with these helpers lets a suite
+// exercise every ServeSegment error branch against a real HTTP server.
+package orchtest
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManifestPrefix marks a manifestID as a fault-injection directive
+// rather than a real stream. It must match the prefix recognized by the
+// testtools-tagged fault injection layer in package server.
+const TestManifestPrefix = "livepeer-test:"
+
+// DirectiveCreds builds a base64-encoded SegmentHeader value whose
+// manifestID carries the given fault-injection directive (e.g.
+// "transcode-error" or "partial-renditions:2/4"). sig is copied in as the
+// signature unchanged, nothing is signed, so the value only passes a
+// VerifySig check that doesn't inspect the signature bytes.
+func DirectiveCreds(directive string, hash []byte, sig []byte) (string, error) {
+	segData := &net.SegData{
+		ManifestId: []byte(TestManifestPrefix + directive),
+		Hash:       hash,
+		Sig:        sig,
+	}
+	buf, err := proto.Marshal(segData)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// RequireError asserts that body is a serialized net.TranscodeResult
+// carrying the given error message.
+func RequireError(t *testing.T, body []byte, want string) {
+	var tr net.TranscodeResult
+	require.Nil(t, proto.Unmarshal(body, &tr))
+
+	res, ok := tr.Result.(*net.TranscodeResult_Error)
+	require.True(t, ok, "expected TranscodeResult_Error, got %T", tr.Result)
+	require.Equal(t, want, res.Error)
+}
+
+// RequireSegmentCount asserts that body is a serialized net.TranscodeResult
+// carrying exactly want rendition segments.
+func RequireSegmentCount(t *testing.T, body []byte, want int) {
+	var tr net.TranscodeResult
+	require.Nil(t, proto.Unmarshal(body, &tr))
+
+	res, ok := tr.Result.(*net.TranscodeResult_Data)
+	require.True(t, ok, "expected TranscodeResult_Data, got %T", tr.Result)
+	require.Equal(t, want, len(res.Data.Segments))
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/stream"
+)
+
+// ReconcilerMode selects how a RedundantBroadcastSession picks a winning
+// result out of the replicas it dispatched a segment to.
+type ReconcilerMode int
+
+const (
+	// FirstSuccess accepts whichever orchestrator responds first without error.
+	FirstSuccess ReconcilerMode = iota
+	// MajorityHash compares a hash of each replica's renditions and keeps
+	// whichever result the plurality of orchestrators agree on.
+	MajorityHash
+	// AllMustAgree fails the segment outright if any replica's hash diverges.
+	AllMustAgree
+)
+
+// ErrReconcileDivergence is returned by AllMustAgree when replicas disagree.
+var ErrReconcileDivergence = errors.New("ErrReconcileDivergence")
+
+// replicaResult pairs a BroadcastSession with the TranscodeData its
+// orchestrator returned (or the error it failed with).
+type replicaResult struct {
+	sess *BroadcastSession
+	res  *net.TranscodeData
+	err  error
+}
+
+// Reconciler picks a winner among the replica results for a single segment
+// and reports which sessions should be settled vs refunded.
+type Reconciler interface {
+	// Reconcile returns the accepted result along with the subset of
+	// replicaResults whose orchestrators produced it, so the caller can
+	// settle payment only for those and refund the rest.
+	Reconcile(replicas []replicaResult) (accepted *net.TranscodeData, winners []replicaResult, err error)
+}
+
+// DivergenceEvent is emitted whenever a Reconciler observes orchestrators
+// disagreeing on a segment's output, so operators can slash or blacklist
+// misbehaving orchestrators downstream.
+type DivergenceEvent struct {
+	ManifestID core.ManifestID
+	Winners    []string
+	Losers     []string
+}
+
+// DivergenceHandler is invoked with every DivergenceEvent a reconciler raises.
+type DivergenceHandler func(DivergenceEvent)
+
+// renditionHash is the value reconcilers compare across replicas: the
+// orchestrator-signed Sig over the rendition set, per the redundancy
+// scheme's "majority-hash" policy.
+func renditionHash(res *net.TranscodeData) string {
+	return string(res.Sig)
+}
+
+// resultGroup buckets replicas whose renditionHash matched, preserving the
+// order in which the hash was first seen so that ties between groups can
+// be broken deterministically (first-seen replica wins) rather than by Go's
+// randomized map iteration order.
+type resultGroup struct {
+	hash string
+	rs   []replicaResult
+}
+
+// groupByHash buckets the successful replicas by renditionHash in
+// first-seen order.
+func groupByHash(replicas []replicaResult) []resultGroup {
+	var groups []resultGroup
+	index := map[string]int{}
+	for _, r := range replicas {
+		if r.err != nil {
+			continue
+		}
+		h := renditionHash(r.res)
+		if i, ok := index[h]; ok {
+			groups[i].rs = append(groups[i].rs, r)
+			continue
+		}
+		index[h] = len(groups)
+		groups = append(groups, resultGroup{hash: h, rs: []replicaResult{r}})
+	}
+	return groups
+}
+
+// pickMajority returns the largest group, breaking ties in favor of
+// whichever group was seen first (i.e. the earliest-listed replica),
+// rather than whichever group Go's map iteration happens to visit last.
+func pickMajority(groups []resultGroup) resultGroup {
+	best := groups[0]
+	for _, g := range groups[1:] {
+		if len(g.rs) > len(best.rs) {
+			best = g
+		}
+	}
+	return best
+}
+
+// firstSuccessReconciler accepts the first replica to return without error.
+type firstSuccessReconciler struct{}
+
+func (firstSuccessReconciler) Reconcile(replicas []replicaResult) (*net.TranscodeData, []replicaResult, error) {
+	for _, r := range replicas {
+		if r.err == nil {
+			return r.res, []replicaResult{r}, nil
+		}
+	}
+	return nil, nil, errors.New("no successful replica")
+}
+
+// majorityHashReconciler groups replicas by a hash of their renditions and
+// keeps the result the plurality agreed on.
+type majorityHashReconciler struct {
+	onDivergence DivergenceHandler
+}
+
+func (m majorityHashReconciler) Reconcile(replicas []replicaResult) (*net.TranscodeData, []replicaResult, error) {
+	groups := groupByHash(replicas)
+	if len(groups) == 0 {
+		return nil, nil, errors.New("no successful replica")
+	}
+
+	winners := pickMajority(groups)
+	if len(groups) > 1 && m.onDivergence != nil {
+		m.onDivergence(divergenceEventFrom(replicas, groups, winners.hash))
+	}
+	return winners.rs[0].res, winners.rs, nil
+}
+
+// allMustAgreeReconciler fails the segment if any replica's hash diverges.
+type allMustAgreeReconciler struct {
+	onDivergence DivergenceHandler
+}
+
+func (a allMustAgreeReconciler) Reconcile(replicas []replicaResult) (*net.TranscodeData, []replicaResult, error) {
+	groups := groupByHash(replicas)
+	if len(groups) == 0 {
+		return nil, nil, errors.New("no successful replica")
+	}
+	if len(groups) != 1 {
+		if a.onDivergence != nil {
+			a.onDivergence(divergenceEventFrom(replicas, groups, pickMajority(groups).hash))
+		}
+		return nil, nil, ErrReconcileDivergence
+	}
+	return groups[0].rs[0].res, groups[0].rs, nil
+}
+
+func divergenceEventFrom(replicas []replicaResult, groups []resultGroup, bestHash string) DivergenceEvent {
+	ev := DivergenceEvent{}
+	for _, r := range replicas {
+		if r.err != nil {
+			continue
+		}
+		ev.ManifestID = r.sess.ManifestID
+		break
+	}
+	for _, g := range groups {
+		for _, r := range g.rs {
+			addr := r.sess.OrchestratorInfo.TranscoderAddress()
+			if g.hash == bestHash {
+				ev.Winners = append(ev.Winners, addr)
+			} else {
+				ev.Losers = append(ev.Losers, addr)
+			}
+		}
+	}
+	return ev
+}
+
+// NewReconciler constructs the Reconciler for the requested mode.
+func NewReconciler(mode ReconcilerMode, onDivergence DivergenceHandler) Reconciler {
+	switch mode {
+	case MajorityHash:
+		return majorityHashReconciler{onDivergence: onDivergence}
+	case AllMustAgree:
+		return allMustAgreeReconciler{onDivergence: onDivergence}
+	default:
+		return firstSuccessReconciler{}
+	}
+}
+
+// RedundantBroadcastSession wraps several BroadcastSessions pointed at
+// distinct orchestrators so a single segment can be sent to all of them in
+// parallel, with the Reconciler deciding which replica's output wins.
+type RedundantBroadcastSession struct {
+	Replicas   []*BroadcastSession
+	Reconciler Reconciler
+}
+
+// NewRedundantBroadcastSession builds a coordinator over the given replica
+// sessions using the given quorum/verification policy.
+func NewRedundantBroadcastSession(replicas []*BroadcastSession, mode ReconcilerMode, onDivergence DivergenceHandler) *RedundantBroadcastSession {
+	return &RedundantBroadcastSession{
+		Replicas:   replicas,
+		Reconciler: NewReconciler(mode, onDivergence),
+	}
+}
+
+// TranscodeSeg forwards seg to every replica's orchestrator concurrently,
+// each with the payment header of its own BroadcastSession (see
+// submitSegment), reconciles the responses, and passes every successful
+// replica whose output was not accepted to refundReplica. Segments
+// returned by losing orchestrators are deleted from their OS
+// (gcLoserSegments), and the losing replicas are also returned to the
+// caller.
+func (rs *RedundantBroadcastSession) TranscodeSeg(seg *stream.HLSSegment) (*net.TranscodeResult, []replicaResult, error) {
+	results := make([]replicaResult, len(rs.Replicas))
+
+	var wg sync.WaitGroup
+	for i, sess := range rs.Replicas {
+		wg.Add(1)
+		go func(i int, sess *BroadcastSession) {
+			defer wg.Done()
+			res, err := submitSegment(sess, seg)
+			results[i] = replicaResult{sess: sess, res: res, err: err}
+		}(i, sess)
+	}
+	wg.Wait()
+
+	accepted, winners, err := rs.Reconciler.Reconcile(results)
+	if err != nil {
+		refundAll(results)
+		return nil, nil, err
+	}
+
+	winnerSet := map[*BroadcastSession]bool{}
+	for _, w := range winners {
+		winnerSet[w.sess] = true
+	}
+	losers := make([]replicaResult, 0, len(results)-len(winners))
+	for _, r := range results {
+		if r.err == nil && !winnerSet[r.sess] {
+			losers = append(losers, r)
+		}
+	}
+	gcLoserSegments(losers)
+	refundLosers(results, winnerSet)
+
+	return &net.TranscodeResult{Result: &net.TranscodeResult_Data{Data: accepted}}, losers, nil
+}
+
+// refundAll is called when reconciliation itself fails (e.g. AllMustAgree
+// divergence): no orchestrator's output was accepted, so every successful
+// replica is passed to refundReplica.
+func refundAll(results []replicaResult) {
+	for _, r := range results {
+		if r.err == nil {
+			refundReplica(r.sess)
+		}
+	}
+}
+
+// refundLosers passes every successful replica that is not among the
+// winners to refundReplica. Winners and replicas that failed with an error
+// are left untouched.
+func refundLosers(results []replicaResult, winners map[*BroadcastSession]bool) {
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		if !winners[r.sess] {
+			refundReplica(r.sess)
+		}
+	}
+}
+
+// refundReplica logs the refund of sess's ticket at debug level. It does
+// not change any payment or ticket state.
+func refundReplica(sess *BroadcastSession) {
+	glog.V(common.DEBUG).Infof("Refunding ticket for unused replica, manifestID=%v", sess.ManifestID)
+}
+
+// gcLoserSegments marks the renditions a losing orchestrator actually
+// stored for garbage collection, by deleting the URL it returned rather
+// than fabricating and deleting an unrelated object.
+func gcLoserSegments(losers []replicaResult) {
+	for _, l := range losers {
+		if l.res == nil {
+			continue
+		}
+		for _, seg := range l.res.Segments {
+			go gcSegmentURL(seg.Url)
+		}
+	}
+}
+
+func gcSegmentURL(url string) {
+	osDriver, err := drivers.ParseOSURL(url, false)
+	if err != nil {
+		glog.Errorf("Unable to parse losing replica segment URL %v for GC: %v", url, err)
+		return
+	}
+	if err := osDriver.NewSession("").DeleteData(url); err != nil {
+		glog.Errorf("Unable to GC losing replica segment %v: %v", url, err)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func replicaWithSig(sig string) replicaResult {
+	return replicaResult{
+		sess: &BroadcastSession{ManifestID: core.ManifestID("m")},
+		res:  &net.TranscodeData{Sig: []byte(sig)},
+	}
+}
+
+func TestMajorityHashReconciler_PicksPlurality(t *testing.T) {
+	replicas := []replicaResult{
+		replicaWithSig("a"),
+		replicaWithSig("a"),
+		replicaWithSig("b"),
+	}
+
+	r := majorityHashReconciler{}
+	accepted, winners, err := r.Reconcile(replicas)
+	require.Nil(t, err)
+	assert.Equal(t, "a", string(accepted.Sig))
+	assert.Equal(t, 2, len(winners))
+}
+
+func TestMajorityHashReconciler_TieIsDeterministic(t *testing.T) {
+	replicas := []replicaResult{
+		replicaWithSig("a"),
+		replicaWithSig("b"),
+	}
+
+	r := majorityHashReconciler{}
+	for i := 0; i < 20; i++ {
+		accepted, _, err := r.Reconcile(replicas)
+		require.Nil(t, err)
+		assert.Equal(t, "a", string(accepted.Sig), "tie must always resolve to the first-seen replica")
+	}
+}
+
+func TestAllMustAgreeReconciler_FailsOnDivergence(t *testing.T) {
+	replicas := []replicaResult{
+		replicaWithSig("a"),
+		replicaWithSig("b"),
+	}
+
+	r := allMustAgreeReconciler{}
+	_, _, err := r.Reconcile(replicas)
+	assert.Equal(t, ErrReconcileDivergence, err)
+}
+
+func TestAllMustAgreeReconciler_AcceptsWhenAllAgree(t *testing.T) {
+	replicas := []replicaResult{
+		replicaWithSig("a"),
+		replicaWithSig("a"),
+	}
+
+	r := allMustAgreeReconciler{}
+	accepted, winners, err := r.Reconcile(replicas)
+	require.Nil(t, err)
+	assert.Equal(t, "a", string(accepted.Sig))
+	assert.Equal(t, 2, len(winners))
+}
+
+func TestFirstSuccessReconciler_SkipsErroredReplicas(t *testing.T) {
+	replicas := []replicaResult{
+		{sess: &BroadcastSession{}, err: assert.AnError},
+		replicaWithSig("a"),
+	}
+
+	r := firstSuccessReconciler{}
+	accepted, winners, err := r.Reconcile(replicas)
+	require.Nil(t, err)
+	assert.Equal(t, "a", string(accepted.Sig))
+	assert.Equal(t, 1, len(winners))
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+)
+
+// SegmentContainer identifies the container format a broadcaster is
+// pushing segments in, and therefore how the orchestrator should interpret
+// and respond to them.
+type SegmentContainer int32
+
+const (
+	// MPEGTS is the existing HLS/MPEG-TS ingest path.
+	MPEGTS SegmentContainer = iota
+	// CMAFInit carries a one-time fMP4 initialization segment (ftyp/moov)
+	// that later CMAFMedia segments reference.
+	CMAFInit
+	// CMAFMedia carries a fragmented MP4 media segment (moof/mdat) that
+	// must follow a previously-ingested CMAFInit for the same rendition.
+	CMAFMedia
+)
+
+func (c SegmentContainer) String() string {
+	switch c {
+	case CMAFInit:
+		return "CMAF_INIT"
+	case CMAFMedia:
+		return "CMAF_MEDIA"
+	default:
+		return "MPEGTS"
+	}
+}
+
+// cmafOutputOpts returns the ffmpeg muxer options for CMAF fragment output.
+// The options are the same for every VideoProfile; p is not consulted.
+// verifySegCreds attaches these to the SegTranscodingMetadata it returns
+// for DASH-ingested segments. The movflags make the mp4 muxer write
+// moof/mdat fragments after an empty moov; they do not request a sidx box.
+func cmafOutputOpts(p ffmpeg.VideoProfile) map[string]string {
+	return map[string]string{
+		"movflags": "frag_keyframe+empty_moov+default_base_moof",
+		"f":        "mp4",
+	}
+}
+
+// cmafMuxOpts computes cmafOutputOpts for every profile in profiles, in
+// order, for attaching to SegTranscodingMetadata.MuxOpts.
+func cmafMuxOpts(profiles []ffmpeg.VideoProfile) []map[string]string {
+	opts := make([]map[string]string, len(profiles))
+	for i, p := range profiles {
+		opts[i] = cmafOutputOpts(p)
+	}
+	return opts
+}
+
+// cmafSegmentName builds the OS object name for a CMAF fragment, keeping
+// init and media segments distinguishable within the same rendition.
+func cmafSegmentName(profName string, container SegmentContainer, seq int64) string {
+	if container == CMAFInit {
+		return fmt.Sprintf("%s/init.mp4", profName)
+	}
+	return fmt.Sprintf("%s/%d.m4s", profName, seq)
+}
@@ -0,0 +1,123 @@
+//go:build testtools
+// +build testtools
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+func init() {
+	faultInjectionHook = injectFault
+}
+
+// testManifestPrefix marks a manifestID as a directive for the
+// fault-injection layer below rather than a real stream, mirroring the
+// "magic content" convention used by Git LFS test servers.
+const testManifestPrefix = "livepeer-test:"
+
+// Fault injection directives recognized after testManifestPrefix.
+const (
+	faultPaymentRequired   = "payment-required"
+	faultVerify403         = "verify-403"
+	faultProcessPaymentErr = "process-payment-fail"
+	faultHashMismatch      = "hash-mismatch"
+	faultTranscodeError    = "transcode-error"
+	faultOSSaveFail        = "os-save-fail"
+	faultSlowTranscodePfx  = "slow-transcode:"
+	faultPartialRenditions = "partial-renditions:"
+)
+
+// injectFault inspects manifestID for a fault-injection directive and, if
+// present, writes the corresponding response directly (an error for most
+// directives; a TranscodeData body for slow-transcode and
+// partial-renditions) and reports that it handled the request. This lets an orchtest black-box suite drive
+// every ServeSegment error branch over real HTTP without a mockOrchestrator.
+func injectFault(w http.ResponseWriter, manifestID string) bool {
+	if !strings.HasPrefix(manifestID, testManifestPrefix) {
+		return false
+	}
+	directive := strings.TrimPrefix(manifestID, testManifestPrefix)
+
+	switch {
+	case directive == faultPaymentRequired:
+		http.Error(w, "payment required", http.StatusPaymentRequired)
+	case directive == faultVerify403:
+		http.Error(w, ErrSegSig.Error(), http.StatusForbidden)
+	case directive == faultProcessPaymentErr:
+		http.Error(w, "ProcessPayment error", http.StatusPaymentRequired)
+	case directive == faultHashMismatch:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case directive == faultTranscodeError:
+		writeTranscodeError(w, "TranscodeSeg error")
+	case directive == faultOSSaveFail:
+		writeTranscodeData(w, nil, []byte("fault"))
+	case strings.HasPrefix(directive, faultSlowTranscodePfx):
+		if d, err := time.ParseDuration(strings.TrimPrefix(directive, faultSlowTranscodePfx)); err == nil {
+			time.Sleep(d)
+		}
+		writeTranscodeData(w, [][]byte{[]byte("slow")}, []byte("slow"))
+	case strings.HasPrefix(directive, faultPartialRenditions):
+		got, want := parsePartialRenditions(strings.TrimPrefix(directive, faultPartialRenditions))
+		segs := make([][]byte, got)
+		for i := range segs {
+			segs[i] = []byte("partial")
+		}
+		_ = want
+		writeTranscodeData(w, segs, []byte("partial"))
+	default:
+		return false
+	}
+	return true
+}
+
+// parsePartialRenditions parses a "2/4" directive into (got, want) rendition
+// counts, so a conformance suite can assert the orchestrator degraded
+// gracefully instead of transcoding every configured profile.
+func parsePartialRenditions(spec string) (got int, want int) {
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	got, _ = strconv.Atoi(parts[0])
+	want, _ = strconv.Atoi(parts[1])
+	return got, want
+}
+
+// writeTranscodeError marshals a net.TranscodeResult_Error, matching what
+// ServeSegment would send for a real TranscodeSeg failure.
+func writeTranscodeError(w http.ResponseWriter, msg string) {
+	tr := &net.TranscodeResult{Result: &net.TranscodeResult_Error{Error: msg}}
+	buf, err := proto.Marshal(tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf)
+}
+
+// writeTranscodeData marshals a net.TranscodeResult_Data with one segment
+// URL per rendition, matching what ServeSegment would send on success.
+func writeTranscodeData(w http.ResponseWriter, renditions [][]byte, sig []byte) {
+	segments := make([]*net.TranscodedSegmentData, len(renditions))
+	for i := range renditions {
+		segments[i] = &net.TranscodedSegmentData{Url: "fault-injected"}
+	}
+	tr := &net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{Segments: segments, Sig: sig},
+		},
+	}
+	buf, err := proto.Marshal(tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf)
+}
@@ -0,0 +1,10 @@
+package server
+
+import "net/http"
+
+// faultInjectionHook lets a testtools build (see segment_faultinjection.go)
+// intercept ServeSegment for reserved "livepeer-test:" manifestIDs. It is a
+// no-op in normal builds so production binaries carry none of this logic.
+var faultInjectionHook = func(w http.ResponseWriter, manifestID string) bool {
+	return false
+}
@@ -0,0 +1,65 @@
+//go:build testtools
+// +build testtools
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/livepeer/go-livepeer/server/orchtest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeSegment_FaultInjection_TranscodeError(t *testing.T) {
+	orch := &mockOrchestrator{}
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+
+	ts := httptest.NewServer(ServeSegment(orch))
+	defer ts.Close()
+
+	creds, err := orchtest.DirectiveCreds("transcode-error", []byte("h"), []byte("s"))
+	require.Nil(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader([]byte("foo")))
+	require.Nil(t, err)
+	req.Header.Set(PaymentHeader, "")
+	req.Header.Set(SegmentHeader, creds)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	body := make([]byte, 1024)
+	n, _ := resp.Body.Read(body)
+
+	orchtest.RequireError(t, body[:n], "TranscodeSeg error")
+}
+
+func TestServeSegment_FaultInjection_PartialRenditions(t *testing.T) {
+	orch := &mockOrchestrator{}
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+
+	ts := httptest.NewServer(ServeSegment(orch))
+	defer ts.Close()
+
+	creds, err := orchtest.DirectiveCreds("partial-renditions:2/4", []byte("h"), []byte("s"))
+	require.Nil(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader([]byte("foo")))
+	require.Nil(t, err)
+	req.Header.Set(PaymentHeader, "")
+	req.Header.Set(SegmentHeader, creds)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	body := make([]byte, 1024)
+	n, _ := resp.Body.Read(body)
+
+	orchtest.RequireSegmentCount(t, body[:n], 2)
+}
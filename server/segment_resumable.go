@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// ContentRangeHeader is the standard HTTP header a broadcaster sets on a
+// chunked PUT to identify the byte range it is sending and the total
+// segment size, e.g. "bytes 0-65535/262144".
+const ContentRangeHeader = "Content-Range"
+
+// uploadCache buffers partial segment uploads across requests for the
+// resumable PUT path. A single process-wide cache is used so progress
+// survives across ServeSegment calls on the same orchestrator.
+var uploadCache SegmentUploadCache = NewMemoryUploadCache(1024, 30*time.Second)
+
+// serveResumableSegment implements the Range/Content-Range based resumable
+// upload flow: a GET reports how much of a segment has been received so
+// far (206, with a Range header), while a PUT appends a chunk and, once
+// the reassembled bytes satisfy the hash committed in md, falls through to
+// the normal payment + transcode path.
+func serveResumableSegment(w http.ResponseWriter, r *http.Request, orch Orchestrator, payment net.Payment, md *core.SegTranscodingMetadata) {
+	if r.Method == http.MethodGet {
+		received := uploadCache.Received(md.ManifestID, md.Seq)
+		if received == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", received-1))
+		w.WriteHeader(http.StatusPartialContent)
+		return
+	}
+
+	offset, total, err := parseContentRange(r.Header.Get(ContentRangeHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chunk, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	received, err := uploadCache.Put(md.ManifestID, md.Seq, offset, chunk)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if received < total {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", received-1))
+		w.WriteHeader(http.StatusPartialContent)
+		return
+	}
+
+	data, ok := uploadCache.Finish(md.ManifestID, md.Seq)
+	if !ok {
+		http.Error(w, "upload not found", http.StatusInternalServerError)
+		return
+	}
+
+	if !matchesSegHash(data, md) {
+		uploadCache.Evict(md.ManifestID, md.Seq)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := orch.ProcessPayment(payment, md.ManifestID); err != nil {
+		uploadCache.Evict(md.ManifestID, md.Seq)
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return
+	}
+
+	transcodeAndRespond(w, orch, md, data)
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header
+// into the chunk's starting offset and the segment's total declared size.
+func parseContentRange(header string) (offset int64, total int64, err error) {
+	if header == "" {
+		return 0, 0, fmt.Errorf("missing %s header", ContentRangeHeader)
+	}
+
+	header = strings.TrimPrefix(header, "bytes ")
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("malformed %s header", ContentRangeHeader)
+	}
+
+	rangeParts := strings.SplitN(parts[0], "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, fmt.Errorf("malformed %s header", ContentRangeHeader)
+	}
+
+	start, err := strconv.ParseInt(rangeParts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed %s header", ContentRangeHeader)
+	}
+	total, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed %s header", ContentRangeHeader)
+	}
+
+	return start, total, nil
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/net"
+
+	"github.com/livepeer/lpms/stream"
+)
+
+// Headers used in the broadcaster <-> orchestrator segment exchange.
+const (
+	PaymentHeader = "Livepeer-Payment"
+	SegmentHeader = "Livepeer-Segment"
+)
+
+// Errors returned to the broadcaster when a segment cannot be accepted.
+var (
+	ErrSegEncoding = errors.New("ErrSegEncoding")
+	ErrSegSig      = errors.New("ErrSegSig")
+)
+
+// genSegCreds generates the SegmentHeader payload a broadcaster attaches to
+// a TranscodeSeg request: it carries everything the orchestrator needs to
+// verify the segment belongs to this session before doing any work.
+func genSegCreds(sess *BroadcastSession, seg *stream.HLSSegment) (string, error) {
+	segData := &net.SegData{
+		ManifestId: []byte(sess.ManifestID),
+		Seq:        seg.SeqNo,
+		Hash:       crypto.Keccak256(seg.Data),
+		Profiles:   common.ProfilesToTranscodeOpts(sess.Profiles),
+		Duration:   int32(seg.Duration * 1000),
+		Fname:      seg.Name,
+		Container:  net.SegData_MPEGTS,
+	}
+
+	sig, err := sess.Broadcaster.Sign(segData.Hash)
+	if err != nil {
+		return "", err
+	}
+	segData.Sig = sig
+
+	data, err := proto.Marshal(segData)
+	if err != nil {
+		glog.Error("Unable to marshal segment credentials: ", err)
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// genDashSegCreds is the DASH-ingest counterpart to genSegCreds: it credits
+// a single fMP4 fragment (either the one-time CMAFInit or a CMAFMedia
+// fragment) instead of an MPEG-TS HLS segment. The broadcaster-side DASH
+// ingest loop that would call this per fragment doesn't exist yet in this
+// tree; today it's only exercised directly by the ServeSegment CMAF tests.
+func genDashSegCreds(sess *BroadcastSession, data []byte, seq int64, container SegmentContainer) (string, error) {
+	var protoContainer net.SegData_SegmentContainer
+	switch container {
+	case CMAFInit:
+		protoContainer = net.SegData_CMAF_INIT
+	case CMAFMedia:
+		protoContainer = net.SegData_CMAF_MEDIA
+	default:
+		protoContainer = net.SegData_MPEGTS
+	}
+
+	segData := &net.SegData{
+		ManifestId: []byte(sess.ManifestID),
+		Seq:        seq,
+		Hash:       crypto.Keccak256(data),
+		Profiles:   common.ProfilesToTranscodeOpts(sess.Profiles),
+		Container:  protoContainer,
+	}
+
+	sig, err := sess.Broadcaster.Sign(segData.Hash)
+	if err != nil {
+		return "", err
+	}
+	segData.Sig = sig
+
+	buf, err := proto.Marshal(segData)
+	if err != nil {
+		glog.Error("Unable to marshal segment credentials: ", err)
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// verifySegCreds checks the SegmentHeader sent by the broadcaster against
+// the orchestrator's view of the world and, if valid, returns the metadata
+// needed to drive transcoding.
+func verifySegCreds(orch Orchestrator, segCreds string, broadcaster ethcommon.Address) (*core.SegTranscodingMetadata, error) {
+	buf, err := base64.StdEncoding.DecodeString(segCreds)
+	if err != nil {
+		glog.Error("Unable to base64-decode segment credentials: ", err)
+		return nil, ErrSegEncoding
+	}
+
+	var segData net.SegData
+	if err := proto.Unmarshal(buf, &segData); err != nil {
+		glog.Error("Unable to unmarshal segment credentials: ", err)
+		return nil, ErrSegEncoding
+	}
+
+	if !orch.VerifySig(broadcaster, string(segData.Hash), segData.Sig) {
+		glog.Error("Sig check failed")
+		return nil, ErrSegSig
+	}
+
+	var container SegmentContainer
+	switch segData.Container {
+	case net.SegData_CMAF_INIT:
+		container = CMAFInit
+	case net.SegData_CMAF_MEDIA:
+		container = CMAFMedia
+	default:
+		container = MPEGTS
+	}
+
+	md := &core.SegTranscodingMetadata{
+		ManifestID: core.ManifestID(segData.ManifestId),
+		Seq:        segData.Seq,
+		Hash:       ethcommon.BytesToHash(segData.Hash),
+		Profiles:   common.TranscodeOptsToProfiles(segData.Profiles),
+		Duration:   segData.Duration,
+		Fname:      segData.Fname,
+		Container:  container,
+	}
+	if container != MPEGTS {
+		// DASH-ingested segments need fMP4 muxer flags, not the MPEG-TS
+		// defaults, so the orchestrator's transcode call emits conformant
+		// CMAF fragments for each profile.
+		md.MuxOpts = cmafMuxOpts(md.Profiles)
+	}
+	return md, nil
+}
+
+// ServeSegment is the orchestrator-side HTTP handler a broadcaster posts
+// transcode requests to. It validates payment and segment credentials,
+// drives the transcode, and returns a serialized net.TranscodeResult.
+func ServeSegment(orch Orchestrator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payment, err := getPayment(r.Header.Get(PaymentHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+			return
+		}
+
+		md, err := verifySegCreds(orch, r.Header.Get(SegmentHeader), payment.Sender)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		if faultInjectionHook(w, string(md.ManifestID)) {
+			return
+		}
+
+		if r.Method == http.MethodPut || r.Method == http.MethodGet {
+			serveResumableSegment(w, r, orch, payment, md)
+			return
+		}
+
+		if err := orch.ProcessPayment(payment, md.ManifestID); err != nil {
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !matchesSegHash(data, md) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		transcodeAndRespond(w, orch, md, data)
+	})
+}
+
+// matchesSegHash reports whether data hashes to the value committed in md.
+func matchesSegHash(data []byte, md *core.SegTranscodingMetadata) bool {
+	return ethcommon.BytesToHash(crypto.Keccak256(data)) == md.Hash
+}
+
+// transcodeAndRespond drives TranscodeSeg for already-verified, already-paid
+// segment bytes and writes the serialized net.TranscodeResult to w. It is
+// shared by the single-shot POST path and the resumable PUT path once a
+// segment has been fully reassembled.
+func transcodeAndRespond(w http.ResponseWriter, orch Orchestrator, md *core.SegTranscodingMetadata, data []byte) {
+	seg := &stream.HLSSegment{Data: data, SeqNo: md.Seq, Name: md.Fname}
+
+	res, err := orch.TranscodeSeg(md, seg)
+	tr := &net.TranscodeResult{}
+	if err != nil {
+		tr.Result = &net.TranscodeResult_Error{Error: err.Error()}
+	} else if md.Container != MPEGTS {
+		tr.Result = saveCMAFFragments(res, md)
+	} else {
+		tr.Result = transcodeResultToProto(res)
+	}
+
+	buf, err := proto.Marshal(tr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf)
+}
+
+// transcodeResultToProto saves each rendition returned by TranscodeSeg to
+// the orchestrator's object store and packages the resulting URLs, keeping
+// any OS save errors distinguishable from transcode errors.
+func transcodeResultToProto(res *core.TranscodeResult) *net.TranscodeResult_Data {
+	segments := []*net.TranscodedSegmentData{}
+	for _, d := range res.Data {
+		uri, err := res.OS.SaveData("", d)
+		if err != nil {
+			glog.Error("Error saving transcoded segment: ", err)
+			continue
+		}
+		segments = append(segments, &net.TranscodedSegmentData{Url: uri})
+	}
+	return &net.TranscodeResult_Data{
+		Data: &net.TranscodeData{
+			Segments: segments,
+			Sig:      res.Sig,
+		},
+	}
+}
@@ -3,10 +3,13 @@ package server
 import (
 	"bytes"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/golang/protobuf/proto"
@@ -20,6 +23,28 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// httpPutResp is the PUT counterpart to the pre-existing httpPostResp
+// helper, needed by the resumable-upload tests below since those exercise
+// ServeSegment's PUT-based Range/Content-Range path.
+func httpPutResp(handler http.Handler, body io.Reader, headers map[string]string) *http.Response {
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL, body)
+	if err != nil {
+		panic(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	return resp
+}
+
 func TestServeSegment_GetPaymentError(t *testing.T) {
 	orch := &mockOrchestrator{}
 	handler := ServeSegment(orch)
@@ -334,3 +359,200 @@ func TestServeSegment_ReturnMultipleTranscodedSegmentData(t *testing.T) {
 	assert.Equal([]byte("foo"), res.Data.Sig)
 	assert.Equal(2, len(res.Data.Segments))
 }
+
+// TestServeSegment_ReturnCMAFFragments covers the two CMAF ingest requests
+// a broadcaster actually makes for a rendition: one CMAFInit request
+// carrying the fMP4 init segment, and one CMAFMedia request per media
+// fragment after it. A single ingest request's res.Data entries all share
+// the request's container (one per configured profile), they are never a
+// init+media pair within the same request.
+func TestServeSegment_ReturnCMAFFragments(t *testing.T) {
+	require := require.New(t)
+
+	s := &BroadcastSession{
+		Broadcaster: StubBroadcaster2(),
+		ManifestID:  core.RandomManifestID(),
+		Profiles: []ffmpeg.VideoProfile{
+			ffmpeg.P720p60fps16x9,
+		},
+	}
+
+	orch := &mockOrchestrator{}
+	handler := ServeSegment(orch)
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+	orch.On("ProcessPayment", net.Payment{}, s.ManifestID).Return(nil)
+
+	initCreds, err := genDashSegCreds(s, []byte("foo"), 0, CMAFInit)
+	require.Nil(err)
+
+	initMD, err := verifySegCreds(orch, initCreds, ethcommon.Address{})
+	require.Nil(err)
+	require.Equal(CMAFInit, initMD.Container)
+
+	initMos := &mockOSSession{}
+	initMos.On("SaveData", s.Profiles[0].Name+"/init.mp4", mock.Anything).Return("/init.mp4", nil)
+	orch.On("TranscodeSeg", initMD, mock.Anything).Return(&core.TranscodeResult{
+		Data: [][]byte{[]byte("init")},
+		Sig:  []byte("foo"),
+		OS:   initMos,
+	}, nil)
+
+	initResp := httpPostResp(handler, bytes.NewReader([]byte("foo")), map[string]string{
+		PaymentHeader: "",
+		SegmentHeader: initCreds,
+	})
+	defer initResp.Body.Close()
+
+	initBody, err := ioutil.ReadAll(initResp.Body)
+	require.Nil(err)
+
+	var initTR net.TranscodeResult
+	require.Nil(proto.Unmarshal(initBody, &initTR))
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, initResp.StatusCode)
+	initRes, ok := initTR.Result.(*net.TranscodeResult_Data)
+	assert.True(ok)
+	assert.Equal(1, len(initRes.Data.Segments))
+	initMos.AssertCalled(t, "SaveData", s.Profiles[0].Name+"/init.mp4", mock.Anything)
+
+	mediaCreds, err := genDashSegCreds(s, []byte("foo"), 0, CMAFMedia)
+	require.Nil(err)
+
+	mediaMD, err := verifySegCreds(orch, mediaCreds, ethcommon.Address{})
+	require.Nil(err)
+	require.Equal(CMAFMedia, mediaMD.Container)
+
+	mediaMos := &mockOSSession{}
+	mediaMos.On("SaveData", s.Profiles[0].Name+"/0.m4s", mock.Anything).Return("/0.m4s", nil)
+	orch.On("TranscodeSeg", mediaMD, mock.Anything).Return(&core.TranscodeResult{
+		Data: [][]byte{[]byte("media")},
+		Sig:  []byte("foo"),
+		OS:   mediaMos,
+	}, nil)
+
+	mediaResp := httpPostResp(handler, bytes.NewReader([]byte("foo")), map[string]string{
+		PaymentHeader: "",
+		SegmentHeader: mediaCreds,
+	})
+	defer mediaResp.Body.Close()
+
+	mediaBody, err := ioutil.ReadAll(mediaResp.Body)
+	require.Nil(err)
+
+	var mediaTR net.TranscodeResult
+	require.Nil(proto.Unmarshal(mediaBody, &mediaTR))
+
+	assert.Equal(http.StatusOK, mediaResp.StatusCode)
+	mediaRes, ok := mediaTR.Result.(*net.TranscodeResult_Data)
+	assert.True(ok)
+	assert.Equal(1, len(mediaRes.Data.Segments))
+	mediaMos.AssertCalled(t, "SaveData", s.Profiles[0].Name+"/0.m4s", mock.Anything)
+}
+
+func TestServeSegment_ResumableUpload_TwoChunks(t *testing.T) {
+	orch := &mockOrchestrator{}
+	handler := ServeSegment(orch)
+
+	require := require.New(t)
+
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+
+	s := &BroadcastSession{
+		Broadcaster: StubBroadcaster2(),
+		ManifestID:  core.RandomManifestID(),
+		Profiles: []ffmpeg.VideoProfile{
+			ffmpeg.P720p60fps16x9,
+		},
+	}
+	seg := &stream.HLSSegment{Data: []byte("foobar")}
+	creds, err := genSegCreds(s, seg)
+	require.Nil(err)
+
+	md, err := verifySegCreds(orch, creds, ethcommon.Address{})
+	require.Nil(err)
+
+	orch.On("ProcessPayment", net.Payment{}, s.ManifestID).Return(nil)
+
+	tRes := &core.TranscodeResult{
+		Data: [][]byte{[]byte("foo")},
+		Sig:  []byte("foo"),
+		OS:   drivers.NewMemoryDriver(nil).NewSession(""),
+	}
+	orch.On("TranscodeSeg", md, mock.Anything).Return(tRes, nil)
+
+	headers1 := map[string]string{
+		PaymentHeader:      "",
+		SegmentHeader:      creds,
+		ContentRangeHeader: "bytes 0-2/6",
+	}
+	resp1 := httpPutResp(handler, bytes.NewReader([]byte("foo")), headers1)
+	defer resp1.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp1.StatusCode)
+
+	headers2 := map[string]string{
+		PaymentHeader:      "",
+		SegmentHeader:      creds,
+		ContentRangeHeader: "bytes 3-5/6",
+	}
+	resp2 := httpPutResp(handler, bytes.NewReader([]byte("bar")), headers2)
+	defer resp2.Body.Close()
+
+	body, err := ioutil.ReadAll(resp2.Body)
+	require.Nil(err)
+
+	var tr net.TranscodeResult
+	err = proto.Unmarshal(body, &tr)
+	require.Nil(err)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, resp2.StatusCode)
+	res, ok := tr.Result.(*net.TranscodeResult_Data)
+	assert.True(ok)
+	assert.Equal(1, len(res.Data.Segments))
+}
+
+func TestServeSegment_ResumableUpload_HashMismatch(t *testing.T) {
+	orch := &mockOrchestrator{}
+	handler := ServeSegment(orch)
+
+	require := require.New(t)
+
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+
+	s := &BroadcastSession{
+		Broadcaster: StubBroadcaster2(),
+		ManifestID:  core.RandomManifestID(),
+	}
+	seg := &stream.HLSSegment{Data: []byte("foobar")}
+	creds, err := genSegCreds(s, seg)
+	require.Nil(err)
+
+	headers := map[string]string{
+		PaymentHeader:      "",
+		SegmentHeader:      creds,
+		ContentRangeHeader: "bytes 0-5/6",
+	}
+	resp := httpPutResp(handler, bytes.NewReader([]byte("wrong!")), headers)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(err)
+
+	assert := assert.New(t)
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	assert.Equal("Forbidden", strings.TrimSpace(string(body)))
+}
+
+func TestMemoryUploadCache_EvictsStalePartials(t *testing.T) {
+	c := NewMemoryUploadCache(4, 20*time.Millisecond)
+
+	manifestID := core.RandomManifestID()
+	_, err := c.Put(manifestID, 0, 0, []byte("partial"))
+	require.Nil(t, err)
+	require.Equal(t, int64(len("partial")), c.Received(manifestID, 0))
+
+	time.Sleep(60 * time.Millisecond)
+
+	assert.Equal(t, int64(0), c.Received(manifestID, 0))
+}
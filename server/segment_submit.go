@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/stream"
+)
+
+// submitSegment POSTs seg to a single orchestrator over the standard
+// ServeSegment HTTP contract, with fresh segment credentials from
+// genSegCreds and the payment header of sess itself (sess.PaymentHeader()),
+// so each replica is paid through its own session, and returns the
+// orchestrator's TranscodeData (or its reported transcode error).
+func submitSegment(sess *BroadcastSession, seg *stream.HLSSegment) (*net.TranscodeData, error) {
+	creds, err := genSegCreds(sess, seg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sess.OrchestratorInfo.TranscoderURL(), bytes.NewReader(seg.Data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(SegmentHeader, creds)
+	req.Header.Set(PaymentHeader, sess.PaymentHeader())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(string(body))
+	}
+
+	var tr net.TranscodeResult
+	if err := proto.Unmarshal(body, &tr); err != nil {
+		return nil, err
+	}
+
+	switch res := tr.Result.(type) {
+	case *net.TranscodeResult_Error:
+		return nil, errors.New(res.Error)
+	case *net.TranscodeResult_Data:
+		return res.Data, nil
+	default:
+		return nil, errors.New("unrecognized TranscodeResult")
+	}
+}
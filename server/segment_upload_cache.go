@@ -0,0 +1,270 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livepeer/go-livepeer/core"
+)
+
+// uploadKey identifies a single in-flight chunked segment upload.
+type uploadKey struct {
+	manifestID core.ManifestID
+	seq        int64
+}
+
+// ErrUploadCacheFull is returned once a SegmentUploadCache implementation
+// has hit its configured concurrency limit for partial uploads.
+var ErrUploadCacheFull = fmt.Errorf("ErrUploadCacheFull")
+
+// SegmentUploadCache buffers the chunks of a segment being uploaded over
+// multiple HTTP requests (Range/Content-Range) until the broadcaster has
+// sent the full body, so a dropped connection only costs the remaining
+// bytes instead of a full re-POST.
+type SegmentUploadCache interface {
+	// Put writes data at offset for the given upload, returning how many
+	// contiguous bytes from offset 0 have been received so far (the same
+	// value Received reports). ErrUploadCacheFull is returned if
+	// this would exceed the cache's configured concurrency limit and the
+	// key isn't already tracked.
+	Put(manifestID core.ManifestID, seq int64, offset int64, data []byte) (int64, error)
+	// Received returns how many contiguous bytes have been buffered.
+	Received(manifestID core.ManifestID, seq int64) int64
+	// Finish returns the fully reassembled bytes and removes the entry.
+	Finish(manifestID core.ManifestID, seq int64) ([]byte, bool)
+	// Evict drops a partial upload without returning its bytes, used for
+	// explicit aborts. The implementations' TTL sweepers drop stale entries
+	// directly rather than through Evict.
+	Evict(manifestID core.ManifestID, seq int64)
+}
+
+// addRange merges [start, end) into ranges, keeping it sorted and with no
+// overlapping or adjacent entries, so contiguousFromZero can trust it's
+// looking at disjoint, ordered spans.
+func addRange(ranges [][2]int64, start, end int64) [][2]int64 {
+	ranges = append(ranges, [2]int64{start, end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] > last[1] {
+			merged = append(merged, r)
+			continue
+		}
+		if r[1] > last[1] {
+			last[1] = r[1]
+		}
+	}
+	return merged
+}
+
+// contiguousFromZero returns how many bytes starting at offset 0 are
+// covered without a gap, which is the only prefix of a chunked upload
+// that's actually safe to treat as "received" — a later, non-adjacent
+// chunk shouldn't count toward completion until the gap before it fills
+// in.
+func contiguousFromZero(ranges [][2]int64) int64 {
+	if len(ranges) == 0 || ranges[0][0] > 0 {
+		return 0
+	}
+	return ranges[0][1]
+}
+
+type partialUpload struct {
+	buf         []byte
+	ranges      [][2]int64
+	lastTouched time.Time
+}
+
+// MemoryUploadCache buffers partial segment uploads in process memory.
+// It is the default for single-node orchestrator deployments.
+type MemoryUploadCache struct {
+	mu       sync.Mutex
+	partials map[uploadKey]*partialUpload
+	maxConcurrent int
+	ttl      time.Duration
+}
+
+// NewMemoryUploadCache returns a MemoryUploadCache that evicts partial
+// uploads untouched for longer than ttl and refuses new uploads once
+// maxConcurrent distinct (manifestID, seq) keys are in flight.
+func NewMemoryUploadCache(maxConcurrent int, ttl time.Duration) *MemoryUploadCache {
+	c := &MemoryUploadCache{
+		partials:      map[uploadKey]*partialUpload{},
+		maxConcurrent: maxConcurrent,
+		ttl:           ttl,
+	}
+	go c.sweep()
+	return c
+}
+
+func (c *MemoryUploadCache) sweep() {
+	for range time.Tick(c.ttl / 2) {
+		c.mu.Lock()
+		now := time.Now()
+		for k, p := range c.partials {
+			if now.Sub(p.lastTouched) > c.ttl {
+				delete(c.partials, k)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *MemoryUploadCache) Put(manifestID core.ManifestID, seq int64, offset int64, data []byte) (int64, error) {
+	k := uploadKey{manifestID, seq}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.partials[k]
+	if !ok {
+		if len(c.partials) >= c.maxConcurrent {
+			return 0, ErrUploadCacheFull
+		}
+		p = &partialUpload{}
+		c.partials[k] = p
+	}
+
+	if need := int(offset) + len(data); need > len(p.buf) {
+		grown := make([]byte, need)
+		copy(grown, p.buf)
+		p.buf = grown
+	}
+	copy(p.buf[offset:], data)
+	p.ranges = addRange(p.ranges, offset, offset+int64(len(data)))
+	p.lastTouched = time.Now()
+	return contiguousFromZero(p.ranges), nil
+}
+
+func (c *MemoryUploadCache) Received(manifestID core.ManifestID, seq int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.partials[uploadKey{manifestID, seq}]
+	if !ok {
+		return 0
+	}
+	return contiguousFromZero(p.ranges)
+}
+
+func (c *MemoryUploadCache) Finish(manifestID core.ManifestID, seq int64) ([]byte, bool) {
+	k := uploadKey{manifestID, seq}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.partials[k]
+	if !ok {
+		return nil, false
+	}
+	delete(c.partials, k)
+	return p.buf, true
+}
+
+func (c *MemoryUploadCache) Evict(manifestID core.ManifestID, seq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.partials, uploadKey{manifestID, seq})
+}
+
+// DiskUploadCache buffers partial segment uploads in a directory on disk,
+// so a large in-flight upload doesn't have to be held entirely in memory.
+// Which byte ranges have arrived is tracked in memory only, so after an
+// orchestrator process restart an upload's progress is lost and its .part
+// file is no longer swept.
+type DiskUploadCache struct {
+	mu            sync.Mutex
+	dir           string
+	maxConcurrent int
+	ttl           time.Duration
+	touched       map[uploadKey]time.Time
+	ranges        map[uploadKey][][2]int64
+}
+
+// NewDiskUploadCache returns a DiskUploadCache rooted at dir.
+func NewDiskUploadCache(dir string, maxConcurrent int, ttl time.Duration) (*DiskUploadCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &DiskUploadCache{
+		dir:           dir,
+		maxConcurrent: maxConcurrent,
+		ttl:           ttl,
+		touched:       map[uploadKey]time.Time{},
+		ranges:        map[uploadKey][][2]int64{},
+	}
+	go c.sweep()
+	return c, nil
+}
+
+func (c *DiskUploadCache) path(k uploadKey) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%d.part", k.manifestID, k.seq))
+}
+
+func (c *DiskUploadCache) sweep() {
+	for range time.Tick(c.ttl / 2) {
+		c.mu.Lock()
+		now := time.Now()
+		for k, t := range c.touched {
+			if now.Sub(t) > c.ttl {
+				os.Remove(c.path(k))
+				delete(c.touched, k)
+				delete(c.ranges, k)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *DiskUploadCache) Put(manifestID core.ManifestID, seq int64, offset int64, data []byte) (int64, error) {
+	k := uploadKey{manifestID, seq}
+	c.mu.Lock()
+	_, tracked := c.touched[k]
+	if !tracked && len(c.touched) >= c.maxConcurrent {
+		c.mu.Unlock()
+		return 0, ErrUploadCacheFull
+	}
+	c.touched[k] = time.Now()
+	c.ranges[k] = addRange(c.ranges[k], offset, offset+int64(len(data)))
+	received := contiguousFromZero(c.ranges[k])
+	c.mu.Unlock()
+
+	f, err := os.OpenFile(c.path(k), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(data, offset); err != nil {
+		return 0, err
+	}
+	return received, nil
+}
+
+func (c *DiskUploadCache) Received(manifestID core.ManifestID, seq int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return contiguousFromZero(c.ranges[uploadKey{manifestID, seq}])
+}
+
+func (c *DiskUploadCache) Finish(manifestID core.ManifestID, seq int64) ([]byte, bool) {
+	k := uploadKey{manifestID, seq}
+	data, err := ioutil.ReadFile(c.path(k))
+	if err != nil {
+		return nil, false
+	}
+	c.Evict(manifestID, seq)
+	return data, true
+}
+
+func (c *DiskUploadCache) Evict(manifestID core.ManifestID, seq int64) {
+	k := uploadKey{manifestID, seq}
+	c.mu.Lock()
+	delete(c.touched, k)
+	delete(c.ranges, k)
+	c.mu.Unlock()
+	os.Remove(c.path(k))
+}